@@ -0,0 +1,77 @@
+// Copyright 2011-2018 Paul Ruane.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/oniony/TMSU/storage/database"
+)
+
+var InfoCommand = Command{
+	Name:     "info",
+	Synopsis: "Shows database information",
+	Usages:   []string{"tmsu db info"},
+	Description: `Shows information about the database: its DSN (with any password redacted), backend product and version, the applied schema migration, on-disk size (SQLite only), table counts and any settings.
+
+Useful for bug reports and for working out which backend capabilities are available.`,
+	Options: Options{},
+	Exec:    infoExec,
+}
+
+// unexported
+
+func infoExec(options Options, args []string, databasePath string) (error, warnings) {
+	db, err := database.OpenAt(databasePath)
+	if err != nil {
+		return err, nil
+	}
+	defer db.Close()
+
+	info, err := db.Info(context.Background(), databasePath)
+	if err != nil {
+		return fmt.Errorf("could not retrieve database information: %v", err), nil
+	}
+
+	fmt.Printf("DSN:               %v\n", info.DSN)
+	fmt.Printf("Backend:           %v %v.%v.%v (%v)\n", info.Backend.Product, info.Backend.Major, info.Backend.Minor, info.Backend.Patch, info.Backend.Raw)
+	fmt.Printf("Schema migration:  %v\n", info.AppliedMigration)
+	if info.HasSize {
+		fmt.Printf("Size:              %v bytes\n", info.SizeBytes)
+	}
+	fmt.Printf("Files:             %v\n", info.FileCount)
+	fmt.Printf("Tags:              %v\n", info.TagCount)
+	fmt.Printf("Values:            %v\n", info.ValueCount)
+	fmt.Printf("File tags:         %v\n", info.FileTagCount)
+
+	if len(info.Settings) > 0 {
+		fmt.Println("Settings:")
+
+		names := make([]string, 0, len(info.Settings))
+		for name := range info.Settings {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Printf("  %v = %v\n", name, info.Settings[name])
+		}
+	}
+
+	return nil, nil
+}
@@ -35,8 +35,15 @@ Creates a .tmsu directory under PATH and initialises a new empty database within
 
 If no PATH is specified then the current working directory is assumed.
 
-The new database is used automatically whenever TMSU is invoked from a directory under PATH (unless overridden by the global --database option or the TMSU_DB environment variable.`,
-	Options: Options{Option{"--root-path", "-P", "root path to use for relative paths; only for networked databases", true, ""},},
+The new database is used automatically whenever TMSU is invoked from a directory under PATH (unless overridden by the global --database option or the TMSU_DB environment variable.
+
+For networked databases, --replica-url configures a read replica that read-only commands are routed to; it can also be overridden at any time via the TMSU_DB_REPLICA environment variable, without re-running init.`,
+	Options: Options{Option{"--root-path", "-P", "root path to use for relative paths; only for networked databases", true, ""},
+		Option{"--schema", "", "schema to create and use; only for PostgreSQL databases", true, ""},
+		Option{"--replica-url", "", "URL of a read replica to route read-only commands to; only for networked databases", true, ""},
+		Option{"--max-open-conns", "", "maximum number of open connections to the database", true, ""},
+		Option{"--max-idle-conns", "", "maximum number of idle connections to keep in the pool", true, ""},
+		Option{"--conn-max-lifetime", "", "maximum lifetime of a connection, e.g. '5m'", true, ""},},
 	Exec:    initExec,
 }
 
@@ -58,18 +65,35 @@ func initExec(options Options, args []string, databasePath string) (error, warni
 
 	warnings := make(warnings, 0, 10)
 	for _, path := range paths {
+		if err := createSchemaIfRequested(options, path); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%v: could not create schema: %v", path, err))
+			continue
+		}
+
 		if err := initializeDatabase(path); err != nil {
 			warnings = append(warnings, fmt.Sprintf("%v: could not initialize database: %v", path, err))
 		} else {
 			if err := insertRootPath(options, path); err != nil {
 				warnings = append(warnings, fmt.Sprintf("%v: could not initialize database with root path: %v", path, err))
 			}
+
+			if err := insertConnectionSettings(options, path); err != nil {
+				warnings = append(warnings, fmt.Sprintf("%v: could not initialize database with connection settings: %v", path, err))
+			}
 		}
 	}
 
 	return nil, warnings
 }
 
+func createSchemaIfRequested(options Options, path string) error {
+	if !options.HasOption("--schema") {
+		return nil
+	}
+
+	return database.CreateSchema(path, options.Get("--schema").Argument)
+}
+
 func initializeDatabase(path string) error {
 	log.Warnf("%v: creating database", path)
 	var dbPath string = path
@@ -101,5 +125,58 @@ func insertRootPath(options Options, path string) error {
 		_, err = database.UpdateSetting(tx, "rootPath", options.Get("--root-path").Argument)
 		return err
 	}
+	return nil
+}
+
+func insertConnectionSettings(options Options, path string) error {
+	hasReplicaUrl := options.HasOption("--replica-url")
+	hasMaxOpenConns := options.HasOption("--max-open-conns")
+	hasMaxIdleConns := options.HasOption("--max-idle-conns")
+	hasConnMaxLifetime := options.HasOption("--conn-max-lifetime")
+
+	if !hasReplicaUrl && !hasMaxOpenConns && !hasMaxIdleConns && !hasConnMaxLifetime {
+		return nil
+	}
+
+	if hasReplicaUrl && !database.HasScheme(path) {
+		return fmt.Errorf("--replica-url is only supported for networked databases")
+	}
+
+	db, err := database.OpenAt(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Commit()
+
+	if hasReplicaUrl {
+		if _, err := database.UpdateSetting(tx, "replicaUrl", options.Get("--replica-url").Argument); err != nil {
+			return err
+		}
+	}
+
+	if hasMaxOpenConns {
+		if _, err := database.UpdateSetting(tx, "maxOpenConns", options.Get("--max-open-conns").Argument); err != nil {
+			return err
+		}
+	}
+
+	if hasMaxIdleConns {
+		if _, err := database.UpdateSetting(tx, "maxIdleConns", options.Get("--max-idle-conns").Argument); err != nil {
+			return err
+		}
+	}
+
+	if hasConnMaxLifetime {
+		if _, err := database.UpdateSetting(tx, "connMaxLifetime", options.Get("--conn-max-lifetime").Argument); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,120 @@
+// Copyright 2011-2018 Paul Ruane.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cli
+
+import (
+	"fmt"
+
+	"github.com/oniony/TMSU/storage/database"
+)
+
+var MigrateCommand = Command{
+	Name:     "migrate",
+	Synopsis: "Applies database schema migrations",
+	Usages: []string{"tmsu db migrate [--to=N]",
+		"tmsu db migrate --status",
+		"tmsu db migrate --down=N"},
+	Description: `Applies pending database schema migrations, reports their status or reverts them.
+
+With no options, applies every migration that has not yet been applied.
+
+--to=N applies migrations up to and including migration N.
+
+--down=N reverts applied migrations newer than migration N.
+
+--status lists each registered migration and whether it has been applied, without changing anything.`,
+	Options: Options{Option{"--to", "", "migrate up to and including the given migration ID", true, ""},
+		Option{"--down", "", "revert migrations newer than the given migration ID", true, ""},
+		Option{"--status", "", "show migration status and exit", false, ""}},
+	Exec: migrateExec,
+}
+
+// unexported
+
+func migrateExec(options Options, args []string, databasePath string) (error, warnings) {
+	db, err := database.OpenAtForMigration(databasePath)
+	if err != nil {
+		return err, nil
+	}
+	defer db.Close()
+
+	switch {
+	case options.HasOption("--status"):
+		return showMigrationStatus(db)
+	case options.HasOption("--down"):
+		return revertMigrations(db, options.Get("--down").Argument)
+	default:
+		return applyMigrations(db, options)
+	}
+}
+
+func applyMigrations(db *database.Database, options Options) (error, warnings) {
+	var to *uint
+	if options.HasOption("--to") {
+		id, err := parseMigrationId(options.Get("--to").Argument)
+		if err != nil {
+			return err, nil
+		}
+
+		to = &id
+	}
+
+	if err := db.Migrate(to); err != nil {
+		return fmt.Errorf("could not apply migrations: %v", err), nil
+	}
+
+	return nil, nil
+}
+
+func revertMigrations(db *database.Database, arg string) (error, warnings) {
+	id, err := parseMigrationId(arg)
+	if err != nil {
+		return err, nil
+	}
+
+	if err := db.MigrateDown(id); err != nil {
+		return fmt.Errorf("could not revert migrations: %v", err), nil
+	}
+
+	return nil, nil
+}
+
+func showMigrationStatus(db *database.Database) (error, warnings) {
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		return fmt.Errorf("could not determine migration status: %v", err), nil
+	}
+
+	for _, status := range statuses {
+		state := "pending"
+		if status.Applied {
+			state = "applied"
+		}
+
+		fmt.Printf("%4d  %-8v  %v\n", status.ID, state, status.Description)
+	}
+
+	return nil, nil
+}
+
+func parseMigrationId(arg string) (uint, error) {
+	var id uint
+	if _, err := fmt.Sscanf(arg, "%d", &id); err != nil {
+		return 0, fmt.Errorf("invalid migration ID '%v'", arg)
+	}
+
+	return id, nil
+}
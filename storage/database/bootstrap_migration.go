@@ -0,0 +1,58 @@
+// Copyright 2011-2018 Paul Ruane.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"fmt"
+
+	"github.com/oniony/TMSU/storage/database/migrations"
+)
+
+// bootstrapMigrationId is reserved for the migration that bridges the
+// pre-existing 'version' table into 'schema_migrations'. Migrations added
+// after this one must use higher IDs.
+const bootstrapMigrationId = 1
+
+func init() {
+	migrations.RegisterMigration(migrations.Migration{
+		ID:          bootstrapMigrationId,
+		Description: "bridge legacy version table into schema_migrations",
+		Up:          bootstrapSchemaMigrations,
+		Down:        func(q migrations.Queryer) error { return nil },
+	})
+}
+
+// bootstrapSchemaMigrations is the bootstrap migration. It does not alter
+// the schema: the 'version' table is left in place, untouched, so that
+// older builds reading it continue to work. Instead it reads the legacy
+// major/minor/patch/revision row and checks it against latestSchemaVersion,
+// the version the pre-migration upgrade() path brought every database to
+// before schema_migrations existed. That check is the actual bridge: it is
+// what lets migration 1 stand in for every schema change the old upgrade()
+// path already applied, so they are not run again under the new framework.
+func bootstrapSchemaMigrations(q migrations.Queryer) error {
+	tx, ok := q.(*Tx)
+	if !ok {
+		return fmt.Errorf("bootstrap migration requires a database transaction")
+	}
+
+	version := currentSchemaVersion(tx)
+	if version != latestSchemaVersion {
+		return fmt.Errorf("cannot bootstrap schema_migrations: legacy version table reports schema %v, expected %v; run the pre-migration upgrade first", version, latestSchemaVersion)
+	}
+
+	return nil
+}
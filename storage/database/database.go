@@ -16,6 +16,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -25,12 +26,14 @@ import (
 	"strings"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq" // initialised PostgreSQL
 	_ "github.com/mattn/go-sqlite3" // initialised Sqlite3
 	"github.com/oniony/TMSU/common/log"
 )
 
 type Database struct {
-	db *sql.DB
+	db        *sql.DB
+	replicaDb *sql.DB
 }
 
 // Return scheme. The part before '://'.
@@ -62,9 +65,14 @@ func HasScheme(path string) bool {
 // Assume that the driver name is also the package name
 // This should be the same name that is used in 'sql.Open'
 // Example: return 'sqlite3' for driver with type '*sqlite3.SQLiteDriver'
+// The lib/pq package is the one exception: its package name is 'pq' but it
+// registers itself under the driver name 'postgres'.
 func GetDriverName(db *sql.DB) string {
 	dbDriverType := reflect.TypeOf(db.Driver()).Elem().String()
 	dbDriverName := strings.Split(dbDriverType, ".")[0]
+	if dbDriverName == "pq" {
+		dbDriverName = "postgres"
+	}
 	for _, name := range sql.Drivers() {
 		if name == dbDriverName {
 			return name
@@ -76,6 +84,10 @@ func GetDriverName(db *sql.DB) string {
 func OpenDB(path string) (*sql.DB, error) {
 	if (HasScheme(path)) {
 		scheme := GetScheme(path)
+		if scheme == "postgres" || scheme == "postgresql" {
+			// lib/pq parses the full 'postgres://'/'postgresql://' URL itself
+			return sql.Open("postgres", path)
+		}
 		dbPath := SplitPathFromScheme(path)
 		return sql.Open(scheme, dbPath)
 	}
@@ -89,7 +101,7 @@ func CreateAt(path string) error {
 	if err != nil {
 		return DatabaseAccessError{path, err}
 	}
-	db := Database{_db}
+	db := Database{db: _db}
 	defer db.Close()
 
 	tx, err := db.Begin()
@@ -105,10 +117,67 @@ func CreateAt(path string) error {
 		return DatabaseTransactionError{path, err}
 	}
 
+	if err := db.Migrate(nil); err != nil {
+		return DatabaseTransactionError{path, err}
+	}
+
+	if err := db.applyConnectionSettings(); err != nil {
+		return DatabaseTransactionError{path, err}
+	}
+
 	return nil
 }
 
+// OpenAt opens the database at 'path' for ordinary use, migrating it to the
+// latest schema in the process. Every command other than 'tmsu db migrate'
+// opens the database this way, so that upgrading the binary and running any
+// command is enough to bring the schema up to date; 'tmsu db migrate' itself
+// uses OpenAtForMigration instead, since it needs to decide for itself which
+// migrations to apply.
 func OpenAt(path string) (*Database, error) {
+	db, err := openAt(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Migrate(nil); err != nil {
+		return nil, DatabaseTransactionError{path, err}
+	}
+
+	if err := db.applyConnectionSettings(); err != nil {
+		return nil, DatabaseTransactionError{path, err}
+	}
+
+	return db, nil
+}
+
+// OpenAtForMigration opens the database at 'path' without migrating it to
+// latest, leaving the schema at whatever schema_migrations records until the
+// caller explicitly applies, reverts or inspects migrations. It exists for
+// 'tmsu db migrate', the one command whose whole purpose is to make that
+// decision itself; every other command should use OpenAt.
+func OpenAtForMigration(path string) (*Database, error) {
+	db, err := openAt(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.ensureSchemaMigrationsTable(); err != nil {
+		return nil, DatabaseTransactionError{path, err}
+	}
+
+	if err := db.applyConnectionSettings(); err != nil {
+		return nil, DatabaseTransactionError{path, err}
+	}
+
+	return db, nil
+}
+
+// openAt does the work common to OpenAt and OpenAtForMigration: opening the
+// underlying connection and running the legacy pre-migration-framework
+// upgrade(). It leaves deciding how far to advance schema_migrations to its
+// callers.
+func openAt(path string) (*Database, error) {
 	log.Infof(2, "opening database at '%v'.", path)
 
 	_, err := os.Stat(path)
@@ -125,7 +194,7 @@ func OpenAt(path string) (*Database, error) {
 	if err != nil {
 		return nil, DatabaseAccessError{path, err}
 	}
-	db := Database{_db}
+	db := Database{db: _db}
 	tx, err := db.Begin()
 	if err != nil {
 		return nil, DatabaseTransactionError{path, err}
@@ -143,25 +212,56 @@ func OpenAt(path string) (*Database, error) {
 }
 
 func (database *Database) Close() error {
+	if database.replicaDb != nil {
+		database.replicaDb.Close()
+	}
+
 	return database.db.Close()
 }
 
+// Begin starts a transaction, resolving its Dialect once from the
+// underlying driver rather than re-deriving it on every query run against
+// the transaction.
 func (database *Database) Begin() (*Tx, error) {
 	tx, err := database.db.Begin()
 	if err != nil {
 		return nil, err
 	}
 
-	return &Tx{tx, GetDriverName(database.db)}, nil
+	return &Tx{tx, dialectForDriver(GetDriverName(database.db))}, nil
 }
 
 type Tx struct {
-	tx *sql.Tx
-	driver string
+	tx      *sql.Tx
+	dialect Dialect
+}
+
+// Dialect returns the Dialect resolved for this transaction's backend.
+func (tx *Tx) Dialect() Dialect {
+	return tx.dialect
 }
 
 func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
-	query = finalizeQuery(tx, query)
+	query, err := finalizeQuery(tx, query)
+	if err != nil {
+		return nil, err
+	}
+	log.Info(3, query)
+	log.Infof(3, "params: %v", args)
+
+	return tx.tx.Exec(query, args...)
+}
+
+// ExecUpsert executes an 'INSERT OR IGNORE'/'INSERT OR REPLACE' style query,
+// translating it for the current dialect. 'conflictColumns' names the
+// columns that make up the unique constraint the upsert targets: dialects
+// such as PostgreSQL require this to build their 'ON CONFLICT' clause, as
+// there is no way to infer it from the query string alone.
+func (tx *Tx) ExecUpsert(query string, conflictColumns []string, args ...interface{}) (sql.Result, error) {
+	query, err := finalizeUpsertQuery(tx, query, conflictColumns)
+	if err != nil {
+		return nil, err
+	}
 	log.Info(3, query)
 	log.Infof(3, "params: %v", args)
 
@@ -169,13 +269,29 @@ func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
 }
 
 func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	query = finalizeQuery(tx, query)
+	query, err := finalizeQuery(tx, query)
+	if err != nil {
+		return nil, err
+	}
 	log.Info(3, query)
 	log.Infof(3, "params: %v", args)
 
 	return tx.tx.Query(query, args...)
 }
 
+// QueryContext is as Query, but takes a context; it exists chiefly so a
+// *Tx satisfies the Queryer interface used by Dialect.Version.
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query, err := finalizeQuery(tx, query)
+	if err != nil {
+		return nil, err
+	}
+	log.Info(3, query)
+	log.Infof(3, "params: %v", args)
+
+	return tx.tx.QueryContext(ctx, query, args...)
+}
+
 func (tx *Tx) Commit() error {
 	log.Info(2, "committing transaction")
 
@@ -207,14 +323,6 @@ func readCount(rows *sql.Rows) (uint, error) {
 	return count, nil
 }
 
-func collationFor(ignoreCase bool) string {
-	if ignoreCase {
-		return " COLLATE NOCASE"
-	}
-
-	return ""
-}
-
 func getCount(tx *Tx, table string, column string) (uint, error) {
 	sql := fmt.Sprintf(`SELECT COUNT(%s) FROM %s`, column, table)
 	rows, err := tx.Query(sql)
@@ -253,13 +361,18 @@ func getNextId(tx *Tx, table string, idColumn string) (uint, error) {
 	return lastID + 1, err
 }
 
-func finalizeQuery(tx *Tx, query string) string {
-	switch tx.driver {
-	case "mysql":
-		return compatMySql(query)
-	default:
-		return query
-	}
+// finalizeQuery and finalizeUpsertQuery translate the SQLite-flavoured raw
+// SQL embedded throughout this package for the transaction's resolved
+// Dialect. They exist so existing call sites don't have to change; new code
+// should prefer building queries via tx.Dialect() directly. They error
+// rather than executing a best-effort translation when the dialect cannot
+// safely rewrite the query (see queryRewriter).
+func finalizeQuery(tx *Tx, query string) (string, error) {
+	return rewrite(tx.dialect, query, nil)
+}
+
+func finalizeUpsertQuery(tx *Tx, query string, conflictColumns []string) (string, error) {
+	return rewrite(tx.dialect, query, conflictColumns)
 }
 
 func compatMySql(query string) string {
@@ -0,0 +1,124 @@
+// Copyright 2011-2018 Paul Ruane.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strconv"
+)
+
+// parseVersion extracts the leading 'major.minor.patch' numbers from a raw
+// version string such as '3.39.4' or '8.0.34-0ubuntu0.22.04.1'.
+func parseVersion(product string, raw string) Version {
+	match := regexp.MustCompile(`(\d+)(?:\.(\d+))?(?:\.(\d+))?`).FindStringSubmatch(raw)
+	version := Version{Product: product, Raw: raw}
+	if match == nil {
+		return version
+	}
+
+	version.Major, _ = strconv.Atoi(match[1])
+	version.Minor, _ = strconv.Atoi(match[2])
+	version.Patch, _ = strconv.Atoi(match[3])
+
+	return version
+}
+
+// Version describes a backend's reported version, as returned by a
+// Dialect's Version method.
+type Version struct {
+	Product string
+	Major   int
+	Minor   int
+	Patch   int
+	Raw     string
+}
+
+// Queryer is the subset of *Tx (or *sql.DB) needed to run a version probe.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Dialect captures the handful of ways the supported backends differ, so
+// that callers build correct SQL for whichever backend is in use rather
+// than relying on finalizeQuery to patch it up afterwards. It is resolved
+// once, when a transaction begins, rather than re-derived from the driver
+// name on every query.
+type Dialect interface {
+	// Placeholder returns the parameter marker for the n'th (1-indexed)
+	// bound argument in a query, e.g. "?" or "$1".
+	Placeholder(n int) string
+
+	// QuoteIdent quotes an identifier (table or column name) for safe use
+	// verbatim in a query.
+	QuoteIdent(name string) string
+
+	// Upsert returns a complete INSERT statement, with the dialect's own
+	// placeholders (see Placeholder) for 'cols' in order, that inserts a new row or updates
+	// the existing one should it conflict on 'conflict'.
+	Upsert(table string, cols []string, conflict []string) string
+
+	// AutoIncrementPK returns the column definition for an auto-assigned
+	// integer primary key.
+	AutoIncrementPK() string
+
+	// IgnoreCaseCollation returns the suffix, if any, that makes a column
+	// reference compare case-insensitively. An empty string means the
+	// dialect adds no suffix: MySQL's default collations already ignore
+	// case, while PostgreSQL comparisons are wrapped with LOWER() instead.
+	IgnoreCaseCollation() string
+
+	// Version reports the backend's product and version.
+	Version(ctx context.Context, queryer Queryer) (Version, error)
+
+	// BooleanLiteral renders a boolean value as a literal usable in SQL.
+	BooleanLiteral(value bool) string
+}
+
+// queryRewriter lets a Dialect translate the SQLite-flavoured raw SQL used
+// throughout the rest of storage/database (embedded '?' placeholders,
+// 'INSERT OR IGNORE'/'INSERT OR REPLACE') into its own dialect. It exists to
+// let existing call sites keep working unchanged while new code is written
+// directly against the Dialect methods above; it is unexported for that
+// reason. It returns an error, rather than silently returning its best
+// effort, when a dialect cannot translate a query safely — notably an
+// 'INSERT OR REPLACE' with no declared conflict columns.
+type queryRewriter interface {
+	rewriteQuery(query string, conflictColumns []string) (string, error)
+}
+
+// dialectForDriver resolves the Dialect for a registered driver name. It is
+// called once, from Database.Begin, rather than on every query.
+func dialectForDriver(driverName string) Dialect {
+	switch driverName {
+	case "mysql":
+		return mysqlDialect{}
+	case "postgres":
+		return postgresDialect{}
+	default:
+		return sqliteDialect{}
+	}
+}
+
+func rewrite(dialect Dialect, query string, conflictColumns []string) (string, error) {
+	rewriter, ok := dialect.(queryRewriter)
+	if !ok {
+		return query, nil
+	}
+
+	return rewriter.rewriteQuery(query, conflictColumns)
+}
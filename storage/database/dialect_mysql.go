@@ -0,0 +1,88 @@
+// Copyright 2011-2018 Paul Ruane.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Placeholder(n int) string { return "?" }
+
+func (mysqlDialect) QuoteIdent(name string) string { return "`" + name + "`" }
+
+func (d mysqlDialect) Upsert(table string, cols []string, conflict []string) string {
+	placeholders := make([]string, len(cols))
+	for index := range cols {
+		placeholders[index] = d.Placeholder(index + 1)
+	}
+
+	updates := make([]string, 0, len(cols))
+	for _, column := range cols {
+		if containsString(conflict, column) {
+			continue
+		}
+
+		updates = append(updates, fmt.Sprintf("%v = VALUES(%v)", column, column))
+	}
+
+	statement := fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v)", table, strings.Join(cols, ", "), strings.Join(placeholders, ", "))
+	if len(updates) == 0 {
+		return statement + " ON DUPLICATE KEY UPDATE " + cols[0] + " = " + cols[0]
+	}
+
+	return statement + " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+}
+
+func (mysqlDialect) AutoIncrementPK() string { return "INT AUTO_INCREMENT PRIMARY KEY" }
+
+// IgnoreCaseCollation returns the empty string: MySQL's default collations
+// are already case-insensitive, so no suffix is required.
+func (mysqlDialect) IgnoreCaseCollation() string { return "" }
+
+func (mysqlDialect) BooleanLiteral(value bool) string {
+	if value {
+		return "1"
+	}
+
+	return "0"
+}
+
+func (mysqlDialect) Version(ctx context.Context, queryer Queryer) (Version, error) {
+	rows, err := queryer.QueryContext(ctx, `SELECT VERSION()`)
+	if err != nil {
+		return Version{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return Version{}, fmt.Errorf("could not determine MySQL version")
+	}
+
+	var raw string
+	if err := rows.Scan(&raw); err != nil {
+		return Version{}, err
+	}
+
+	return parseVersion("MySQL", raw), rows.Err()
+}
+
+func (mysqlDialect) rewriteQuery(query string, conflictColumns []string) (string, error) {
+	return compatMySql(query), nil
+}
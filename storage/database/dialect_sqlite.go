@@ -0,0 +1,74 @@
+// Copyright 2011-2018 Paul Ruane.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// sqliteDialect is the default dialect, and the one the rest of
+// storage/database's embedded SQL is written against natively.
+type sqliteDialect struct{}
+
+func (sqliteDialect) Placeholder(n int) string { return "?" }
+
+func (sqliteDialect) QuoteIdent(name string) string { return "`" + name + "`" }
+
+func (d sqliteDialect) Upsert(table string, cols []string, conflict []string) string {
+	placeholders := make([]string, len(cols))
+	for index := range cols {
+		placeholders[index] = d.Placeholder(index + 1)
+	}
+
+	return fmt.Sprintf("INSERT OR REPLACE INTO %v (%v) VALUES (%v)", table, strings.Join(cols, ", "), strings.Join(placeholders, ", "))
+}
+
+func (sqliteDialect) AutoIncrementPK() string { return "INTEGER PRIMARY KEY" }
+
+func (sqliteDialect) IgnoreCaseCollation() string { return " COLLATE NOCASE" }
+
+func (sqliteDialect) BooleanLiteral(value bool) string {
+	if value {
+		return "1"
+	}
+
+	return "0"
+}
+
+func (sqliteDialect) Version(ctx context.Context, queryer Queryer) (Version, error) {
+	rows, err := queryer.QueryContext(ctx, `SELECT sqlite_version()`)
+	if err != nil {
+		return Version{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return Version{}, fmt.Errorf("could not determine SQLite version")
+	}
+
+	var raw string
+	if err := rows.Scan(&raw); err != nil {
+		return Version{}, err
+	}
+
+	return parseVersion("SQLite", raw), rows.Err()
+}
+
+func (sqliteDialect) rewriteQuery(query string, conflictColumns []string) (string, error) {
+	return query, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2011-2018 Paul Ruane.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"database/sql"
+	"testing"
+)
+
+// TestDialectRoundtrip exercises the pure, connection-free Dialect methods
+// against all three supported dialects. The end-to-end schema check for the
+// SQLite dialect (the only backend reachable without an external server) is
+// TestCreateSchemaSqlite.
+func TestDialectRoundtrip(t *testing.T) {
+	dialects := map[string]Dialect{
+		"sqlite":   sqliteDialect{},
+		"mysql":    mysqlDialect{},
+		"postgres": postgresDialect{},
+	}
+
+	for name, dialect := range dialects {
+		t.Run(name, func(t *testing.T) {
+			if dialect.Placeholder(1) == "" {
+				t.Errorf("%v: Placeholder returned an empty string", name)
+			}
+
+			if quoted := dialect.QuoteIdent("value"); quoted == "value" {
+				t.Errorf("%v: QuoteIdent did not quote 'value'", name)
+			}
+
+			if pk := dialect.AutoIncrementPK(); pk == "" {
+				t.Errorf("%v: AutoIncrementPK returned an empty string", name)
+			}
+
+			upsert := dialect.Upsert("tag", []string{"id", "name"}, []string{"id"})
+			if upsert == "" {
+				t.Errorf("%v: Upsert returned an empty string", name)
+			}
+
+			if dialect.BooleanLiteral(true) == dialect.BooleanLiteral(false) {
+				t.Errorf("%v: BooleanLiteral(true) and BooleanLiteral(false) must differ", name)
+			}
+		})
+	}
+}
+
+// TestCreateSchemaSqlite proves createSchema, as rewired onto
+// Dialect.AutoIncrementPK and Dialect.QuoteIdent, still produces a working
+// SQLite schema that basic file-tag CRUD can run against.
+func TestCreateSchemaSqlite(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("could not open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	sqlTx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("could not begin transaction: %v", err)
+	}
+
+	tx := &Tx{sqlTx, sqliteDialect{}}
+
+	if err := createSchema(tx); err != nil {
+		t.Fatalf("createSchema failed: %v", err)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO tag (id, name) VALUES (?, ?)`, 1, "pinned"); err != nil {
+		t.Fatalf("could not insert tag: %v", err)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO file (id, directory, name, fingerprint, mod_time, size, is_dir) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		1, "/tmp", "example.txt", "abc123", "2018-01-01 00:00:00", 0, false); err != nil {
+		t.Fatalf("could not insert file: %v", err)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO file_tag (file_id, tag_id, value_id) VALUES (?, ?, ?)`, 1, 1, 0); err != nil {
+		t.Fatalf("could not insert file_tag: %v", err)
+	}
+
+	count, err := getCount(tx, "file_tag", "file_id")
+	if err != nil {
+		t.Fatalf("could not count file_tag rows: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 file_tag row, got %v", count)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("could not commit transaction: %v", err)
+	}
+}
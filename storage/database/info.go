@@ -0,0 +1,176 @@
+// Copyright 2011-2018 Paul Ruane.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"os"
+	"regexp"
+)
+
+// Info reports everything useful for a bug report or for capability
+// gating: the backend's product and version, the schema migration the
+// database is currently at, its size (where that is meaningful), table
+// counts and the contents of the 'setting' table.
+type Info struct {
+	DSN              string
+	Backend          Version
+	AppliedMigration uint
+	SizeBytes        int64
+	HasSize          bool
+	FileCount        uint
+	TagCount         uint
+	ValueCount       uint
+	FileTagCount     uint
+	Settings         map[string]string
+}
+
+// Version reports the backend's product and version, e.g. to decide
+// whether a capability such as WITH RECURSIVE-based implication expansion
+// can be used.
+func (database *Database) Version(ctx context.Context) (Version, error) {
+	tx, err := database.BeginRead()
+	if err != nil {
+		return Version{}, err
+	}
+	defer tx.Commit()
+
+	return tx.Dialect().Version(ctx, tx)
+}
+
+// Info gathers the information described by the Info struct. 'path' is the
+// DSN the database was opened with, used to report its (password-redacted)
+// form and, for SQLite, to stat the file for its on-disk size.
+func (database *Database) Info(ctx context.Context, path string) (Info, error) {
+	tx, err := database.BeginRead()
+	if err != nil {
+		return Info{}, err
+	}
+	defer tx.Commit()
+
+	backend, err := tx.Dialect().Version(ctx, tx)
+	if err != nil {
+		return Info{}, err
+	}
+
+	fileCount, err := getCount(tx, "file", "id")
+	if err != nil {
+		return Info{}, err
+	}
+
+	tagCount, err := getCount(tx, "tag", "id")
+	if err != nil {
+		return Info{}, err
+	}
+
+	valueCount, err := getCount(tx, tx.Dialect().QuoteIdent("value"), "id")
+	if err != nil {
+		return Info{}, err
+	}
+
+	fileTagCount, err := getCount(tx, "file_tag", "file_id")
+	if err != nil {
+		return Info{}, err
+	}
+
+	settings, err := readAllSettings(tx)
+	if err != nil {
+		return Info{}, err
+	}
+
+	info := Info{
+		DSN:              RedactDSN(path),
+		Backend:          backend,
+		AppliedMigration: latestAppliedMigration(tx),
+		FileCount:        fileCount,
+		TagCount:         tagCount,
+		ValueCount:       valueCount,
+		FileTagCount:     fileTagCount,
+		Settings:         settings,
+	}
+
+	if !HasScheme(path) {
+		if stat, err := os.Stat(path); err == nil {
+			info.SizeBytes = stat.Size()
+			info.HasSize = true
+		}
+	}
+
+	return info, nil
+}
+
+// RedactDSN returns 'path' with any embedded password replaced with '***',
+// so that it is safe to log or print, e.g. in a bug report.
+func RedactDSN(path string) string {
+	return regexp.MustCompile(`(://[^:@/]+:)([^@]+)(@)`).ReplaceAllString(path, "${1}***${3}")
+}
+
+// unexported
+
+func latestAppliedMigration(tx *Tx) uint {
+	var latest uint
+	for _, migration := range migrationsApplied(tx) {
+		if migration > latest {
+			latest = migration
+		}
+	}
+
+	return latest
+}
+
+func migrationsApplied(tx *Tx) []uint {
+	rows, err := tx.Query(`SELECT id FROM schema_migrations`)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var ids []uint
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			return ids
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func readAllSettings(tx *Tx) (map[string]string, error) {
+	sql := `
+SELECT name, value
+FROM setting`
+
+	rows, err := tx.Query(sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+
+		settings[name] = value
+	}
+
+	return settings, rows.Err()
+}
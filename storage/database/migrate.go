@@ -0,0 +1,224 @@
+// Copyright 2011-2018 Paul Ruane.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/oniony/TMSU/common/log"
+	"github.com/oniony/TMSU/storage/database/migrations"
+)
+
+// MigrationStatus describes a registered migration and whether it has been
+// applied to the database.
+type MigrationStatus struct {
+	ID          uint
+	Description string
+	Applied     bool
+}
+
+// Migrate applies all registered migrations that have not yet been applied.
+// If 'to' is non-nil then only migrations with an ID up to and including
+// 'to' are applied. Each migration is applied within its own transaction:
+// success is recorded before that transaction commits, so a failure partway
+// through leaves earlier migrations applied and later ones untouched.
+func (database *Database) Migrate(to *uint) error {
+	if err := database.ensureSchemaMigrationsTable(); err != nil {
+		return err
+	}
+
+	for _, migration := range migrations.Registered() {
+		if to != nil && migration.ID > *to {
+			break
+		}
+
+		applied, err := database.migrationApplied(migration.ID)
+		if err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+
+		log.Infof(2, "applying migration %v: %v", migration.ID, migration.Description)
+
+		tx, err := database.Begin()
+		if err != nil {
+			return err
+		}
+
+		if err := migration.Up(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %v (%v) failed: %v", migration.ID, migration.Description, err)
+		}
+
+		if err := recordMigration(tx, migration.ID); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %v (%v) could not be committed: %v", migration.ID, migration.Description, err)
+		}
+	}
+
+	return nil
+}
+
+// MigrateDown reverts any applied migration with an ID greater than 'to', in
+// descending order, each within its own transaction.
+func (database *Database) MigrateDown(to uint) error {
+	if err := database.ensureSchemaMigrationsTable(); err != nil {
+		return err
+	}
+
+	registered := migrations.Registered()
+	for index := len(registered) - 1; index >= 0; index-- {
+		migration := registered[index]
+		if migration.ID <= to {
+			break
+		}
+
+		applied, err := database.migrationApplied(migration.ID)
+		if err != nil {
+			return err
+		}
+		if !applied {
+			continue
+		}
+
+		log.Infof(2, "reverting migration %v: %v", migration.ID, migration.Description)
+
+		tx, err := database.Begin()
+		if err != nil {
+			return err
+		}
+
+		if err := migration.Down(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %v (%v) could not be reverted: %v", migration.ID, migration.Description, err)
+		}
+
+		if err := unrecordMigration(tx, migration.ID); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %v (%v) could not be committed: %v", migration.ID, migration.Description, err)
+		}
+	}
+
+	return nil
+}
+
+// MigrationStatus reports each registered migration and whether it has been
+// applied to this database. It is read-only, so once the schema_migrations
+// table is confirmed to exist it queries via BeginRead rather than Begin.
+func (database *Database) MigrationStatus() ([]MigrationStatus, error) {
+	if err := database.ensureSchemaMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	tx, err := database.BeginRead()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Commit()
+
+	statuses := make([]MigrationStatus, 0, len(migrations.Registered()))
+	for _, migration := range migrations.Registered() {
+		applied, err := appliedMigration(tx, migration.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		statuses = append(statuses, MigrationStatus{migration.ID, migration.Description, applied})
+	}
+
+	return statuses, nil
+}
+
+// unexported
+
+func (database *Database) ensureSchemaMigrationsTable() error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+
+	sql := fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    id %v,
+    applied_at DATETIME NOT NULL
+)`, tx.Dialect().AutoIncrementPK())
+
+	if _, err := tx.Exec(sql); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("could not create schema_migrations table: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func (database *Database) migrationApplied(id uint) (bool, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Commit()
+
+	return appliedMigration(tx, id)
+}
+
+func appliedMigration(tx *Tx, id uint) (bool, error) {
+	sql := `
+SELECT COUNT(id)
+FROM schema_migrations
+WHERE id = ?`
+
+	rows, err := tx.Query(sql, id)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	count, err := readCount(rows)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func recordMigration(tx *Tx, id uint) error {
+	sql := `
+INSERT INTO schema_migrations (id, applied_at)
+VALUES (?, ?)`
+
+	_, err := tx.Exec(sql, id, time.Now().UTC())
+	return err
+}
+
+func unrecordMigration(tx *Tx, id uint) error {
+	sql := `
+DELETE FROM schema_migrations
+WHERE id = ?`
+
+	_, err := tx.Exec(sql, id)
+	return err
+}
@@ -0,0 +1,61 @@
+// Copyright 2011-2018 Paul Ruane.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package migrations provides the registry of schema migrations applied by
+// the database package. Migrations are registered here, independently of
+// any particular driver, so that the runner in storage/database can apply
+// them in ID order inside its own transactions.
+package migrations
+
+import (
+	"database/sql"
+	"sort"
+)
+
+// Queryer is the subset of *database.Tx that a migration needs in order to
+// apply or revert itself. It is defined here, rather than importing the
+// database package, to avoid a cyclic dependency between the runner and the
+// registry.
+type Queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Migration is a single, numbered schema change. Up applies the change and
+// Down reverts it. IDs must be unique and are applied in ascending order.
+type Migration struct {
+	ID          uint
+	Description string
+	Up          func(Queryer) error
+	Down        func(Queryer) error
+}
+
+var registered []Migration
+
+// RegisterMigration adds a migration to the registry. It is intended to be
+// called from an init() function in the file that defines the migration.
+func RegisterMigration(migration Migration) {
+	registered = append(registered, migration)
+}
+
+// Registered returns the registered migrations sorted by ascending ID.
+func Registered() []Migration {
+	migrations := make([]Migration, len(registered))
+	copy(migrations, registered)
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].ID < migrations[j].ID })
+
+	return migrations
+}
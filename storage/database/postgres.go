@@ -0,0 +1,214 @@
+// Copyright 2011-2018 Paul Ruane.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/oniony/TMSU/common/log"
+)
+
+type postgresDialect struct{}
+
+func (postgresDialect) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+func (postgresDialect) QuoteIdent(name string) string { return quotePostgresIdent(name) }
+
+func (d postgresDialect) Upsert(table string, cols []string, conflict []string) string {
+	placeholders := make([]string, len(cols))
+	for index := range cols {
+		placeholders[index] = d.Placeholder(index + 1)
+	}
+
+	statement := fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v)", table, strings.Join(cols, ", "), strings.Join(placeholders, ", "))
+
+	// Unlike the legacy rewriteQuery path, this does not error: when
+	// 'conflict' is empty no conflict target can be named, and the
+	// statement falls back to 'ON CONFLICT DO NOTHING', leaving a
+	// conflicting row unchanged.
+	clause, err := postgresUpsertClause(statement, conflict)
+	if err != nil {
+		clause = "ON CONFLICT DO NOTHING"
+	}
+
+	return statement + " " + clause
+}
+
+func (postgresDialect) AutoIncrementPK() string { return "SERIAL PRIMARY KEY" }
+
+// IgnoreCaseCollation returns the empty string: PostgreSQL has no built-in
+// NOCASE collation, so case-insensitive comparisons are built with LOWER()
+// instead.
+func (postgresDialect) IgnoreCaseCollation() string { return "" }
+
+func (postgresDialect) BooleanLiteral(value bool) string {
+	if value {
+		return "TRUE"
+	}
+
+	return "FALSE"
+}
+
+func (postgresDialect) Version(ctx context.Context, queryer Queryer) (Version, error) {
+	rows, err := queryer.QueryContext(ctx, `SHOW server_version`)
+	if err != nil {
+		return Version{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return Version{}, fmt.Errorf("could not determine PostgreSQL version")
+	}
+
+	var raw string
+	if err := rows.Scan(&raw); err != nil {
+		return Version{}, err
+	}
+
+	return parseVersion("PostgreSQL", raw), rows.Err()
+}
+
+func (postgresDialect) rewriteQuery(query string, conflictColumns []string) (string, error) {
+	return compatPostgres(query, conflictColumns)
+}
+
+// CreateSchema creates (if necessary) and switches to the named PostgreSQL
+// schema at 'path', so that 'createSchema' subsequently runs against it
+// rather than 'public'. It is a no-op for non-PostgreSQL paths.
+func CreateSchema(path string, schemaName string) error {
+	if GetScheme(path) != "postgres" && GetScheme(path) != "postgresql" {
+		return nil
+	}
+
+	db, err := OpenDB(path)
+	if err != nil {
+		return DatabaseAccessError{path, err}
+	}
+	defer db.Close()
+
+	quotedSchema := quotePostgresIdent(schemaName)
+
+	log.Infof(2, "creating schema '%v'", schemaName)
+
+	if _, err := db.Exec(fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS %v`, quotedSchema)); err != nil {
+		return fmt.Errorf("could not create schema '%v': %v", schemaName, err)
+	}
+
+	if _, err := db.Exec(fmt.Sprintf(`SET search_path TO %v`, quotedSchema)); err != nil {
+		return fmt.Errorf("could not set search_path to '%v': %v", schemaName, err)
+	}
+
+	return nil
+}
+
+// unexported
+
+var postgresPlaceholder = regexp.MustCompile(`\?`)
+var postgresNocaseCompare = regexp.MustCompile(`(\w+(?:\.\w+)?)\s*=\s*\?\s*COLLATE\s+NOCASE`)
+var postgresNocaseRemainder = regexp.MustCompile(`(?i)\s*COLLATE\s+NOCASE`)
+var postgresInsertOrIgnore = regexp.MustCompile(`(?i)INSERT\s+OR\s+IGNORE\s+`)
+var postgresInsertOrReplace = regexp.MustCompile(`(?i)INSERT\s+OR\s+REPLACE\s+`)
+var postgresInsertColumns = regexp.MustCompile(`(?is)INSERT\s+INTO\s+\S+\s*\(([^)]+)\)`)
+
+// compatPostgres rewrites a SQLite-flavoured query for PostgreSQL: it
+// translates '?' parameters to '$1'..'$N', turns 'INSERT OR IGNORE'/'INSERT
+// OR REPLACE' into 'ON CONFLICT' upserts and drops SQLite's NOCASE
+// collation, preferring a LOWER() comparison where the pattern is
+// recognised. 'conflictColumns' gives the unique constraint an 'INSERT OR
+// REPLACE' should upsert on. An 'INSERT OR REPLACE' with no conflict
+// columns returns an error rather than silently degrading to 'ON CONFLICT
+// DO NOTHING' (which would drop the row's update instead of applying it):
+// callers that need to upsert must go through Tx.ExecUpsert.
+func compatPostgres(query string, conflictColumns []string) (string, error) {
+	query = postgresNocaseCompare.ReplaceAllString(query, "LOWER($1) = LOWER(?)")
+	// TODO: anything left over degrades to a case-sensitive comparison; a
+	// real fix needs the Dialect abstraction to rewrite both sides of the
+	// comparison rather than pattern-matching the query text.
+	query = postgresNocaseRemainder.ReplaceAllString(query, "")
+
+	if postgresInsertOrIgnore.MatchString(query) {
+		query = postgresInsertOrIgnore.ReplaceAllString(query, "INSERT ")
+		query = strings.TrimRight(query, "\n\t ;") + " ON CONFLICT DO NOTHING"
+	}
+
+	if postgresInsertOrReplace.MatchString(query) {
+		query = postgresInsertOrReplace.ReplaceAllString(query, "INSERT ")
+
+		clause, err := postgresUpsertClause(query, conflictColumns)
+		if err != nil {
+			return "", err
+		}
+
+		query = strings.TrimRight(query, "\n\t ;") + " " + clause
+	}
+
+	n := 0
+	query = postgresPlaceholder.ReplaceAllStringFunc(query, func(string) string {
+		n++
+		return fmt.Sprintf("$%d", n)
+	})
+
+	return query, nil
+}
+
+// postgresUpsertClause builds the 'ON CONFLICT' clause for an upsert,
+// updating every inserted column that is not part of the conflict target.
+// It errors rather than falling back to 'ON CONFLICT DO NOTHING' when it
+// cannot determine a real conflict target, since that would silently turn
+// a replace into a no-op.
+func postgresUpsertClause(query string, conflictColumns []string) (string, error) {
+	if len(conflictColumns) == 0 {
+		return "", fmt.Errorf("cannot translate 'INSERT OR REPLACE' for PostgreSQL without conflict columns; use Tx.ExecUpsert")
+	}
+
+	match := postgresInsertColumns.FindStringSubmatch(query)
+	if match == nil {
+		return "", fmt.Errorf("cannot translate 'INSERT OR REPLACE' for PostgreSQL: could not parse the column list")
+	}
+
+	sets := make([]string, 0)
+	for _, column := range strings.Split(match[1], ",") {
+		column = strings.Trim(strings.TrimSpace(column), "`\"")
+		if containsString(conflictColumns, column) {
+			continue
+		}
+
+		sets = append(sets, fmt.Sprintf("%v = EXCLUDED.%v", column, column))
+	}
+
+	if len(sets) == 0 {
+		return fmt.Sprintf("ON CONFLICT (%v) DO NOTHING", strings.Join(conflictColumns, ", ")), nil
+	}
+
+	return fmt.Sprintf("ON CONFLICT (%v) DO UPDATE SET %v", strings.Join(conflictColumns, ", "), strings.Join(sets, ", ")), nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func quotePostgresIdent(ident string) string {
+	return `"` + strings.Replace(ident, `"`, `""`, -1) + `"`
+}
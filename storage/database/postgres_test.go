@@ -0,0 +1,102 @@
+// Copyright 2011-2018 Paul Ruane.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCompatPostgresPlaceholders checks '?' parameters are renumbered to
+// PostgreSQL's '$1'..'$N' form, in order, regardless of how many there are.
+func TestCompatPostgresPlaceholders(t *testing.T) {
+	query, err := compatPostgres(`SELECT * FROM file WHERE directory = ? AND name = ?`, nil)
+	if err != nil {
+		t.Fatalf("compatPostgres returned an error: %v", err)
+	}
+
+	want := `SELECT * FROM file WHERE directory = $1 AND name = $2`
+	if query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+}
+
+// TestCompatPostgresNocaseCollation checks a recognised 'x = ? COLLATE
+// NOCASE' comparison is rewritten to a LOWER() comparison, since PostgreSQL
+// has no NOCASE collation.
+func TestCompatPostgresNocaseCollation(t *testing.T) {
+	query, err := compatPostgres(`SELECT * FROM tag WHERE name = ? COLLATE NOCASE`, nil)
+	if err != nil {
+		t.Fatalf("compatPostgres returned an error: %v", err)
+	}
+
+	want := `SELECT * FROM tag WHERE LOWER(name) = LOWER($1)`
+	if query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+}
+
+// TestCompatPostgresInsertOrIgnore checks 'INSERT OR IGNORE' becomes a plain
+// insert with an 'ON CONFLICT DO NOTHING' clause.
+func TestCompatPostgresInsertOrIgnore(t *testing.T) {
+	query, err := compatPostgres(`INSERT OR IGNORE INTO tag (id, name) VALUES (?, ?)`, nil)
+	if err != nil {
+		t.Fatalf("compatPostgres returned an error: %v", err)
+	}
+
+	if !strings.HasPrefix(query, "INSERT INTO tag (id, name) VALUES ($1, $2)") {
+		t.Errorf("got %q, expected a plain INSERT", query)
+	}
+	if !strings.Contains(query, "ON CONFLICT DO NOTHING") {
+		t.Errorf("got %q, expected an ON CONFLICT DO NOTHING clause", query)
+	}
+}
+
+// TestCompatPostgresInsertOrReplace checks 'INSERT OR REPLACE' becomes an
+// upsert that updates every column not part of the supplied conflict target.
+func TestCompatPostgresInsertOrReplace(t *testing.T) {
+	query, err := compatPostgres(`INSERT OR REPLACE INTO setting (name, value) VALUES (?, ?)`, []string{"name"})
+	if err != nil {
+		t.Fatalf("compatPostgres returned an error: %v", err)
+	}
+
+	if !strings.Contains(query, "ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value") {
+		t.Errorf("got %q, expected an ON CONFLICT ... DO UPDATE clause", query)
+	}
+}
+
+// TestCompatPostgresInsertOrReplaceWithoutConflictColumns checks that an
+// 'INSERT OR REPLACE' with no declared conflict columns errors rather than
+// silently degrading to 'ON CONFLICT DO NOTHING', which would drop the
+// caller's update instead of applying it.
+func TestCompatPostgresInsertOrReplaceWithoutConflictColumns(t *testing.T) {
+	_, err := compatPostgres(`INSERT OR REPLACE INTO setting (name, value) VALUES (?, ?)`, nil)
+	if err == nil {
+		t.Fatal("expected an error, got none")
+	}
+}
+
+// TestQuotePostgresIdent checks identifiers are double-quoted and embedded
+// double quotes are escaped.
+func TestQuotePostgresIdent(t *testing.T) {
+	if got, want := quotePostgresIdent("value"), `"value"`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got, want := quotePostgresIdent(`va"lue`), `"va""lue"`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2011-2018 Paul Ruane.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/oniony/TMSU/common/log"
+)
+
+// replicaUrlEnvVar overrides the 'replicaUrl' setting, letting a deployment
+// point at a replica without touching the database itself.
+const replicaUrlEnvVar = "TMSU_DB_REPLICA"
+
+// BeginRead starts a transaction for read-only use. It is served by the
+// read replica when one is configured, falling back to the primary
+// otherwise. Commands that only issue SELECTs (file, tag and value
+// listings, query evaluation) should use BeginRead; anything that mutates
+// data must use Begin, which always targets the primary.
+func (database *Database) BeginRead() (*Tx, error) {
+	db := database.db
+	if database.replicaDb != nil {
+		db = database.replicaDb
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tx{tx, dialectForDriver(GetDriverName(db))}, nil
+}
+
+// unexported
+
+// connectionSettings are persisted in the 'setting' table and applied to
+// the primary (and, where it exists, the replica) connection pool each time
+// the database is opened.
+type connectionSettings struct {
+	replicaUrl      string
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+}
+
+// applyConnectionSettings reads connectionSettings from the 'setting' table,
+// applies the pool settings to the primary connection and, if a replica URL
+// is configured (by setting or by the TMSU_DB_REPLICA environment
+// variable, which takes precedence), opens and configures it too.
+func (database *Database) applyConnectionSettings() error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Commit()
+
+	settings, err := readConnectionSettings(tx)
+	if err != nil {
+		return err
+	}
+
+	applyPoolSettings(database.db, settings)
+
+	replicaUrl := os.Getenv(replicaUrlEnvVar)
+	if replicaUrl == "" {
+		replicaUrl = settings.replicaUrl
+	}
+	if replicaUrl == "" {
+		return nil
+	}
+
+	log.Infof(2, "opening replica database at '%v'", replicaUrl)
+
+	replicaDb, err := OpenDB(replicaUrl)
+	if err != nil {
+		return fmt.Errorf("could not open replica database: %v", err)
+	}
+
+	applyPoolSettings(replicaDb, settings)
+	database.replicaDb = replicaDb
+
+	return nil
+}
+
+func readConnectionSettings(tx *Tx) (connectionSettings, error) {
+	var settings connectionSettings
+
+	replicaUrl, err := readSetting(tx, "replicaUrl")
+	if err != nil {
+		return settings, err
+	}
+	settings.replicaUrl = replicaUrl
+
+	maxOpenConns, err := readIntSetting(tx, "maxOpenConns")
+	if err != nil {
+		return settings, err
+	}
+	settings.maxOpenConns = maxOpenConns
+
+	maxIdleConns, err := readIntSetting(tx, "maxIdleConns")
+	if err != nil {
+		return settings, err
+	}
+	settings.maxIdleConns = maxIdleConns
+
+	connMaxLifetime, err := readSetting(tx, "connMaxLifetime")
+	if err != nil {
+		return settings, err
+	}
+	if connMaxLifetime != "" {
+		lifetime, err := time.ParseDuration(connMaxLifetime)
+		if err != nil {
+			return settings, fmt.Errorf("invalid connMaxLifetime setting '%v': %v", connMaxLifetime, err)
+		}
+		settings.connMaxLifetime = lifetime
+	}
+
+	return settings, nil
+}
+
+func readIntSetting(tx *Tx, name string) (int, error) {
+	value, err := readSetting(tx, name)
+	if err != nil || value == "" {
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v setting '%v': %v", name, value, err)
+	}
+
+	return n, nil
+}
+
+func readSetting(tx *Tx, name string) (string, error) {
+	sql := `
+SELECT value
+FROM setting
+WHERE name = ?`
+
+	rows, err := tx.Query(sql, name)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return "", rows.Err()
+	}
+
+	var value string
+	if err := rows.Scan(&value); err != nil {
+		return "", err
+	}
+
+	return value, rows.Err()
+}
+
+func applyPoolSettings(db *sql.DB, settings connectionSettings) {
+	if settings.maxOpenConns > 0 {
+		db.SetMaxOpenConns(settings.maxOpenConns)
+	}
+	if settings.maxIdleConns > 0 {
+		db.SetMaxIdleConns(settings.maxIdleConns)
+	}
+	if settings.connMaxLifetime > 0 {
+		db.SetConnMaxLifetime(settings.connMaxLifetime)
+	}
+}
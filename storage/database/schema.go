@@ -136,11 +136,11 @@ func createSchema(tx *Tx) error {
 }
 
 func createTagTable(tx *Tx) error {
-	sql := `
+	sql := fmt.Sprintf(`
 CREATE TABLE IF NOT EXISTS tag (
-    id INTEGER PRIMARY KEY,
+    id %v,
     name VARCHAR(255) NOT NULL
-)`
+)`, tx.Dialect().AutoIncrementPK())
 
 	if _, err := tx.Exec(sql); err != nil {
 		return err
@@ -158,9 +158,9 @@ ON tag(name)`
 }
 
 func createFileTable(tx *Tx) error {
-	sql := `
+	sql := fmt.Sprintf(`
 CREATE TABLE IF NOT EXISTS file (
-    id INTEGER PRIMARY KEY,
+    id %v,
     directory VARCHAR(255) NOT NULL,
     name VARCHAR(255) NOT NULL,
     fingerprint VARCHAR(255) NOT NULL,
@@ -168,7 +168,7 @@ CREATE TABLE IF NOT EXISTS file (
     size INTEGER NOT NULL,
     is_dir BOOLEAN NOT NULL,
     CONSTRAINT con_file_path UNIQUE (directory, name)
-)`
+)`, tx.Dialect().AutoIncrementPK())
 
 	if _, err := tx.Exec(sql); err != nil {
 		return err
@@ -186,12 +186,12 @@ ON file(fingerprint)`
 }
 
 func createValueTable(tx *Tx) error {
-	sql := `
-CREATE TABLE IF NOT EXISTS ` + "`value`" + ` (
-    id INTEGER PRIMARY KEY,
+	sql := fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %v (
+    id %v,
     name VARCHAR(255) NOT NULL,
     CONSTRAINT con_value_name UNIQUE (name)
-)`
+)`, tx.Dialect().QuoteIdent("value"), tx.Dialect().AutoIncrementPK())
 
 	if _, err := tx.Exec(sql); err != nil {
 		return err
@@ -201,7 +201,7 @@ CREATE TABLE IF NOT EXISTS ` + "`value`" + ` (
 }
 
 func createFileTagTable(tx *Tx) error {
-	sql := `
+	sql := fmt.Sprintf(`
 CREATE TABLE IF NOT EXISTS file_tag (
     file_id INTEGER NOT NULL,
     tag_id INTEGER NOT NULL,
@@ -209,8 +209,8 @@ CREATE TABLE IF NOT EXISTS file_tag (
     PRIMARY KEY (file_id, tag_id, value_id),
     FOREIGN KEY (file_id) REFERENCES file(id),
     FOREIGN KEY (tag_id) REFERENCES tag(id),
-    FOREIGN KEY (value_id) REFERENCES ` + "`value`" + `(id)
-)`
+    FOREIGN KEY (value_id) REFERENCES %v(id)
+)`, tx.Dialect().QuoteIdent("value"))
 
 	if _, err := tx.Exec(sql); err != nil {
 		return err
@@ -309,9 +309,9 @@ CREATE TABLE IF NOT EXISTS version (
 // ! ID: 0 should not be used but name must have a value else weird recursive loops occur in vfs
 // TODO: Explicitly exclude ID: 0 from all queries
 func insertDefaultValue(tx *Tx) error {
-	sql := `
-INSERT INTO ` + "`value`" + ` (id, name)
-VALUES (?, ?)`
+	sql := fmt.Sprintf(`
+INSERT INTO %v (id, name)
+VALUES (?, ?)`, tx.Dialect().QuoteIdent("value"))
 	result, err := tx.Exec(sql, 0, "dummy")
 	if err != nil {
 		return fmt.Errorf("could not insert default value: %v", err)